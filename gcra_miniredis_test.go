@@ -0,0 +1,53 @@
+package ratelimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redis "github.com/go-redis/redis/v8"
+)
+
+// TestGCRAStoreTakeAgainstMiniredis runs gcraScript through a real Lua
+// interpreter via miniredis, rather than fakeGCRARedisClient's Go
+// reimplementation of the TAT algorithm. TestGCRAStoreTake exercises
+// gcraStore's argument shape and reply parsing but would miss a typo or
+// logic bug in the committed Lua source itself.
+func TestGCRAStoreTakeAgainstMiniredis(t *testing.T) {
+	t.Parallel()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis run: %v", err)
+	}
+	defer mr.Close()
+
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	s := NewGCRAStore(client, GCRAConfig{Interval: time.Second, Limit: 10, Burst: 2})
+
+	ctx := context.Background()
+
+	// burst+1 requests should be admitted back-to-back
+	for i := 0; i < 3; i++ {
+		limit, _, _, ok, err := s.Take(ctx, "key")
+		if err != nil {
+			t.Fatalf("take %d: %v", i, err)
+		}
+
+		if !ok {
+			t.Fatalf("take %d: expected ok=true within burst", i)
+		}
+
+		if limit != 10 {
+			t.Errorf("take %d: limit = %d, want 10", i, limit)
+		}
+	}
+
+	// the next request, immediately after, should be paced out
+	if _, _, _, ok, err := s.Take(ctx, "key"); err != nil || ok {
+		t.Errorf("take after burst: ok=%v err=%v, want ok=false", ok, err)
+	}
+}
@@ -0,0 +1,117 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// fakeGCRARedisClient evaluates the gcraScript's algorithm directly in Go
+// (TAT tracking per key) instead of a real Lua interpreter, so the
+// gcraStore.Take wiring (argument shape, reply parsing) is exercised without
+// a live Redis instance.
+type fakeGCRARedisClient struct {
+	RedisClient
+
+	mu  sync.Mutex
+	tat map[string]int64
+}
+
+func newFakeGCRARedisClient() *fakeGCRARedisClient {
+	return &fakeGCRARedisClient{tat: make(map[string]int64)}
+}
+
+func (f *fakeGCRARedisClient) Eval(_ context.Context, _ string, keys []string, args ...interface{}) *redis.Cmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := keys[0]
+	now := args[0].(int64)
+	emissionInterval := args[1].(int64)
+	burst := int64(args[2].(uint64))
+
+	tat, ok := f.tat[key]
+	if !ok || tat < now {
+		tat = now
+	}
+
+	newTat := tat + emissionInterval
+	allowAt := newTat - (burst+1)*emissionInterval
+
+	if now < allowAt {
+		cmd := redis.NewCmd(context.Background())
+		cmd.SetVal([]interface{}{int64(0), tat, int64(0)})
+
+		return cmd
+	}
+
+	f.tat[key] = newTat
+
+	remaining := (burst*emissionInterval - (newTat - now)) / emissionInterval
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	cmd := redis.NewCmd(context.Background())
+	cmd.SetVal([]interface{}{remaining, newTat, int64(1)})
+
+	return cmd
+}
+
+func TestGCRAStoreTake(t *testing.T) {
+	t.Parallel()
+
+	client := newFakeGCRARedisClient()
+	s := NewGCRAStore(client, GCRAConfig{Interval: time.Second, Limit: 10, Burst: 2})
+
+	ctx := context.Background()
+
+	// burst+1 requests should be admitted back-to-back
+	for i := 0; i < 3; i++ {
+		limit, _, _, ok, err := s.Take(ctx, "key")
+		if err != nil {
+			t.Fatalf("take %d: %v", i, err)
+		}
+
+		if !ok {
+			t.Fatalf("take %d: expected ok=true within burst", i)
+		}
+
+		if limit != 10 {
+			t.Errorf("take %d: limit = %d, want 10", i, limit)
+		}
+	}
+
+	// the next request, immediately after, should be paced out
+	if _, _, _, ok, err := s.Take(ctx, "key"); err != nil || ok {
+		t.Errorf("take after burst: ok=%v err=%v, want ok=false", ok, err)
+	}
+}
+
+func TestGCRAStoreTakeZeroLimit(t *testing.T) {
+	t.Parallel()
+
+	client := newFakeGCRARedisClient()
+	s := NewGCRAStore(client, GCRAConfig{Interval: time.Second, Limit: 0})
+
+	if _, _, _, _, err := s.Take(context.Background(), "key"); err == nil {
+		t.Errorf("expected an error for Limit=0, got nil")
+	}
+}
+
+func TestGCRAStoreTakeExclUnsupported(t *testing.T) {
+	t.Parallel()
+
+	client := newFakeGCRARedisClient()
+	s := NewGCRAStore(client, GCRAConfig{Interval: time.Second, Limit: 10})
+
+	_, _, _, ok, err := s.TakeExcl(context.Background(), "key", func(string) (bool, uint64, time.Duration) {
+		return true, 100, time.Minute
+	})
+	if err == nil || ok {
+		t.Errorf("expected TakeExcl to return an error, got ok=%v err=%v", ok, err)
+	}
+}
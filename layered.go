@@ -0,0 +1,341 @@
+package ratelimiter
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFlushInterval = 250 * time.Millisecond
+	defaultMaxBatchSize  = 64
+)
+
+// LocalCache is an in-process cache used by the layered store to short
+// circuit Redis round-trips for hot or denied keys.
+type LocalCache interface {
+	// Get returns the cached bucket state for key, if present and not expired.
+	Get(key string) (limit, remaining, resetTime uint64, found bool)
+	// Set stores the bucket state for key, with resetTime also doubling as
+	// the entry's TTL.
+	Set(key string, limit, remaining, resetTime uint64)
+	// Decrement optimistically lowers the cached remaining count by one,
+	// returning the new bucket state and whether the key was present and
+	// had remaining capacity.
+	Decrement(key string) (limit, remaining, resetTime uint64, found bool)
+	// Delete removes a single key from the cache.
+	Delete(key string)
+	// Clear empties the cache entirely.
+	Clear()
+}
+
+// LayeredOption configures NewLayeredStore.
+type LayeredOption func(*layeredStore)
+
+// WithFlushInterval sets how often buffered deltas are flushed to the
+// underlying store. Defaults to 250ms.
+func WithFlushInterval(d time.Duration) LayeredOption {
+	return func(s *layeredStore) {
+		s.flushInterval = d
+	}
+}
+
+// WithMaxBatchSize sets the number of buffered deltas that force an
+// immediate flush rather than waiting for the flush interval. Defaults to 64.
+func WithMaxBatchSize(n int) LayeredOption {
+	return func(s *layeredStore) {
+		s.maxBatchSize = n
+	}
+}
+
+// NewLayeredStore wraps primary with an in-process LocalCache, serving denied
+// requests straight from the cache and batching successful decrements before
+// reconciling them against primary. This cuts store round-trips for skewed
+// traffic where a small number of keys dominate.
+func NewLayeredStore(primary Store, cache LocalCache, opts ...LayeredOption) Store {
+	s := &layeredStore{
+		primary:       primary,
+		cache:         cache,
+		flushInterval: defaultFlushInterval,
+		maxBatchSize:  defaultMaxBatchSize,
+		deltas:        make(map[string]uint64),
+		done:          make(chan struct{}),
+	}
+
+	for _, o := range opts {
+		o(s)
+	}
+
+	go s.flushLoop()
+
+	return s
+}
+
+var _ Store = (*layeredStore)(nil)
+
+// layeredStore impl of Store, combining a LocalCache with an underlying Store
+type layeredStore struct {
+	primary Store
+	cache   LocalCache
+
+	flushInterval time.Duration
+	maxBatchSize  int
+
+	mu      sync.Mutex
+	deltas  map[string]uint64 // local decrements accumulated per key since the last flush
+	pending int               // sum of deltas' values, for the maxBatchSize trigger
+	done    chan struct{}
+}
+
+// Take serves denied requests from the local cache when possible. A cached
+// key with remaining capacity is decremented locally and the decrement is
+// buffered for the next flush. Otherwise it falls through to primary and
+// caches the result.
+func (s *layeredStore) Take(ctx context.Context, key string) (limit, remaining, resetTime uint64, ok bool, err error) {
+	if limit, remaining, resetTime, found := s.cache.Get(key); found && remaining == 0 && uint64(time.Now().UnixNano()) < resetTime {
+		return limit, 0, resetTime, false, nil
+	}
+
+	if limit, remaining, resetTime, found := s.cache.Decrement(key); found {
+		s.buffer(key)
+
+		return limit, remaining, resetTime, true, nil
+	}
+
+	limit, remaining, resetTime, ok, err = s.primary.Take(ctx, key)
+	if err != nil {
+		return 0, 0, 0, false, fmt.Errorf("take: %w", err)
+	}
+
+	s.cache.Set(key, limit, remaining, resetTime)
+
+	return limit, remaining, resetTime, ok, nil
+}
+
+// Reset clears both the local cache and the underlying store.
+func (s *layeredStore) Reset(ctx context.Context) error {
+	s.cache.Clear()
+
+	if err := s.primary.Reset(ctx); err != nil {
+		return fmt.Errorf("reset: %w", err)
+	}
+
+	return nil
+}
+
+// TakeExcl delegates straight to primary, bypassing the local cache: an
+// exclusive override's effective limit/interval can differ per call, which
+// the cache has no way to key on, so exclusive takes are never buffered or
+// served from the cache.
+func (s *layeredStore) TakeExcl(ctx context.Context, key string, f ExclFunc) (limit, remaining, resetTime uint64, ok bool, err error) {
+	limit, remaining, resetTime, ok, err = s.primary.TakeExcl(ctx, key, f)
+	if err != nil {
+		return 0, 0, 0, false, fmt.Errorf("take excl: %w", err)
+	}
+
+	return limit, remaining, resetTime, ok, nil
+}
+
+// Close stops the background flush loop. Safe to call once; callers that
+// create short-lived stores (tests, per-tenant instances, config reloads)
+// should call it to avoid leaking the goroutine for the life of the process.
+func (s *layeredStore) Close() error {
+	close(s.done)
+
+	return nil
+}
+
+// buffer records one local decrement against key, flushing immediately once
+// the accumulated deltas reach maxBatchSize.
+func (s *layeredStore) buffer(key string) {
+	s.mu.Lock()
+	s.deltas[key]++
+	s.pending++
+	shouldFlush := s.pending >= s.maxBatchSize
+	s.mu.Unlock()
+
+	if shouldFlush {
+		s.flush()
+	}
+}
+
+func (s *layeredStore) flushLoop() {
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// flush reconciles every key's accumulated local decrements against primary
+// by applying that many Take calls, then overwrites the local cache with the
+// authoritative state primary returned for the last of them. If primary
+// errors partway through a key's replay (e.g. a transient outage), the
+// decrements that didn't make it are re-buffered rather than dropped, so the
+// next flush retries them instead of leaving primary permanently
+// under-decremented relative to what the local cache already served.
+func (s *layeredStore) flush() {
+	s.mu.Lock()
+	deltas := s.deltas
+	s.deltas = make(map[string]uint64, len(deltas))
+	s.pending = 0
+	s.mu.Unlock()
+
+	ctx := context.Background()
+
+	for key, count := range deltas {
+		var (
+			limit, remaining, resetTime uint64
+			applied                     uint64
+			err                         error
+		)
+
+		for ; applied < count; applied++ {
+			limit, remaining, resetTime, _, err = s.primary.Take(ctx, key)
+			if err != nil {
+				break
+			}
+		}
+
+		if unapplied := count - applied; unapplied > 0 {
+			s.mu.Lock()
+			s.deltas[key] += unapplied
+			s.pending += int(unapplied)
+			s.mu.Unlock()
+		}
+
+		if err != nil {
+			continue
+		}
+
+		s.cache.Set(key, limit, remaining, resetTime)
+	}
+}
+
+// lruEntry is a single bucket cached by LRUCache.
+type lruEntry struct {
+	key       string
+	limit     uint64
+	remaining uint64
+	resetTime uint64
+}
+
+// LRUCache is a size-capped LocalCache whose entries expire according to the
+// resetTime they were stored with.
+type LRUCache struct {
+	mu sync.Mutex
+
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+// NewLRUCache makes an LRUCache holding up to size entries.
+func NewLRUCache(size int) *LRUCache {
+	return &LRUCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+func (c *LRUCache) Get(key string) (limit, remaining, resetTime uint64, found bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return 0, 0, 0, false
+	}
+
+	entry := el.Value.(*lruEntry)
+	if uint64(time.Now().UnixNano()) >= entry.resetTime {
+		c.removeElement(el)
+
+		return 0, 0, 0, false
+	}
+
+	c.ll.MoveToFront(el)
+
+	return entry.limit, entry.remaining, entry.resetTime, true
+}
+
+func (c *LRUCache) Set(key string, limit, remaining, resetTime uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.limit = limit
+		entry.remaining = remaining
+		entry.resetTime = resetTime
+		c.ll.MoveToFront(el)
+
+		return
+	}
+
+	el := c.ll.PushFront(&lruEntry{key: key, limit: limit, remaining: remaining, resetTime: resetTime})
+	c.items[key] = el
+
+	if c.size > 0 && c.ll.Len() > c.size {
+		c.removeOldest()
+	}
+}
+
+func (c *LRUCache) Decrement(key string) (limit, remaining, resetTime uint64, found bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return 0, 0, 0, false
+	}
+
+	entry := el.Value.(*lruEntry)
+	if uint64(time.Now().UnixNano()) >= entry.resetTime || entry.remaining == 0 {
+		return 0, 0, 0, false
+	}
+
+	entry.remaining--
+	c.ll.MoveToFront(el)
+
+	return entry.limit, entry.remaining, entry.resetTime, true
+}
+
+func (c *LRUCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+func (c *LRUCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	c.items = make(map[string]*list.Element, c.size)
+}
+
+func (c *LRUCache) removeOldest() {
+	el := c.ll.Back()
+	if el != nil {
+		c.removeElement(el)
+	}
+}
+
+func (c *LRUCache) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	entry := el.Value.(*lruEntry)
+	delete(c.items, entry.key)
+}
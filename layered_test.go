@@ -0,0 +1,204 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestLayeredStoreCumulativeAdmitsRespectLimit guards against the flush
+// reconciliation bug where a single primary.Take per flush cycle (rather
+// than one per buffered local decrement) kept refilling the local cache
+// near the configured limit, letting a hot key blow past Points by a large
+// multiple.
+func TestLayeredStoreCumulativeAdmitsRespectLimit(t *testing.T) {
+	t.Parallel()
+
+	const points = 10
+
+	primary := NewMemoryStore(MemoryConfig{Interval: time.Hour, Points: points})
+	t.Cleanup(func() { _ = primary.(*memoryStore).Close() })
+
+	cache := NewLRUCache(16)
+
+	store := NewLayeredStore(primary, cache, WithFlushInterval(time.Hour), WithMaxBatchSize(1<<30))
+	ls := store.(*layeredStore)
+	t.Cleanup(func() { _ = ls.Close() })
+
+	ctx := context.Background()
+
+	admitted := 0
+
+	for i := 0; i < 100; i++ {
+		_, _, _, ok, err := ls.Take(ctx, "hot")
+		if err != nil {
+			t.Fatalf("take %d: %v", i, err)
+		}
+
+		if ok {
+			admitted++
+		}
+
+		// Reconcile mid-stream, before the cache would naturally hit zero,
+		// to exercise the flush path while local decrements are still buffered.
+		if i%3 == 0 {
+			ls.flush()
+		}
+	}
+
+	if admitted > points {
+		t.Errorf("cumulative admits = %d, must never exceed configured Points = %d", admitted, points)
+	}
+}
+
+func TestLayeredStoreCacheHitPropagatesLimitAndResetTime(t *testing.T) {
+	t.Parallel()
+
+	primary := NewMemoryStore(MemoryConfig{Interval: time.Hour, Points: 5})
+	t.Cleanup(func() { _ = primary.(*memoryStore).Close() })
+
+	cache := NewLRUCache(16)
+
+	store := NewLayeredStore(primary, cache, WithFlushInterval(time.Hour), WithMaxBatchSize(1<<30))
+	t.Cleanup(func() { _ = store.(*layeredStore).Close() })
+
+	ctx := context.Background()
+
+	// First call populates the cache from primary.
+	limit, _, resetTime, ok, err := store.Take(ctx, "key")
+	if err != nil || !ok {
+		t.Fatalf("first take: ok=%v err=%v", ok, err)
+	}
+
+	if limit != 5 {
+		t.Fatalf("first take limit = %d, want 5", limit)
+	}
+
+	// Second call is served from the cache's Decrement path.
+	limit, _, cachedResetTime, ok, err := store.Take(ctx, "key")
+	if err != nil || !ok {
+		t.Fatalf("second take: ok=%v err=%v", ok, err)
+	}
+
+	if limit != 5 {
+		t.Errorf("cache-hit limit = %d, want 5", limit)
+	}
+
+	if cachedResetTime != resetTime {
+		t.Errorf("cache-hit resetTime = %d, want %d", cachedResetTime, resetTime)
+	}
+}
+
+// TestLayeredStoreTakeExclDelegatesToPrimaryAndBypassesCache guards against
+// the panic("implement me") landmine TakeExcl used to carry: wiring
+// WithExclFunc against a layered store must no longer panic, and since an
+// exclusive override's limit/interval can vary per call, the local cache
+// must not end up caching it under the key's normal limit.
+func TestLayeredStoreTakeExclDelegatesToPrimaryAndBypassesCache(t *testing.T) {
+	t.Parallel()
+
+	primary := NewMemoryStore(MemoryConfig{Interval: time.Minute, Points: 1})
+	t.Cleanup(func() { _ = primary.(*memoryStore).Close() })
+
+	cache := NewLRUCache(16)
+
+	store := NewLayeredStore(primary, cache, WithFlushInterval(time.Hour), WithMaxBatchSize(1<<30))
+	t.Cleanup(func() { _ = store.(*layeredStore).Close() })
+
+	ctx := context.Background()
+
+	excl := func(string) (bool, uint64, time.Duration) { return true, 5, time.Minute }
+
+	limit, remaining, _, ok, err := store.TakeExcl(ctx, "key", excl)
+	if err != nil || !ok {
+		t.Fatalf("take excl: ok=%v err=%v", ok, err)
+	}
+
+	if limit != 5 || remaining != 4 {
+		t.Errorf("take excl: limit=%d remaining=%d, want limit=5 remaining=4", limit, remaining)
+	}
+
+	if _, _, _, found := cache.Get("key"); found {
+		t.Errorf("expected TakeExcl to bypass the local cache, but key was cached")
+	}
+}
+
+// flushErrorStore wraps a Store, injecting a one-time error on the callOn'th
+// Take call, to exercise layeredStore.flush's requeue-on-error path without a
+// generated mock.
+type flushErrorStore struct {
+	Store
+
+	mu     sync.Mutex
+	calls  int
+	callOn int
+}
+
+func (s *flushErrorStore) Take(ctx context.Context, key string) (limit, remaining, resetTime uint64, ok bool, err error) {
+	s.mu.Lock()
+	s.calls++
+	fail := s.calls == s.callOn
+	s.mu.Unlock()
+
+	if fail {
+		return 0, 0, 0, false, fmt.Errorf("injected error")
+	}
+
+	return s.Store.Take(ctx, key)
+}
+
+// TestLayeredStoreFlushRequeuesUnappliedDecrementsOnError guards against the
+// bug where a primary.Take error partway through replaying a key's buffered
+// decrements dropped the remainder on the floor (the deltas map had already
+// been cleared), permanently under-decrementing primary relative to what the
+// local cache already served.
+func TestLayeredStoreFlushRequeuesUnappliedDecrementsOnError(t *testing.T) {
+	t.Parallel()
+
+	const points = 10
+
+	memory := NewMemoryStore(MemoryConfig{Interval: time.Hour, Points: points})
+	t.Cleanup(func() { _ = memory.(*memoryStore).Close() })
+
+	primary := &flushErrorStore{Store: memory, callOn: 3}
+	cache := NewLRUCache(16)
+
+	store := NewLayeredStore(primary, cache, WithFlushInterval(time.Hour), WithMaxBatchSize(1<<30))
+	ls := store.(*layeredStore)
+	t.Cleanup(func() { _ = ls.Close() })
+
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		if _, _, _, ok, err := ls.Take(ctx, "hot"); err != nil || !ok {
+			t.Fatalf("take %d: ok=%v err=%v", i, ok, err)
+		}
+	}
+
+	// The 1st Take went straight to primary (call 1) and the other 4 were
+	// buffered. Replaying them, call 2 succeeds and call 3 fails, so the
+	// failed decrement and the 2 after it must be requeued rather than lost.
+	ls.flush()
+
+	ls.mu.Lock()
+	requeued := ls.deltas["hot"]
+	ls.mu.Unlock()
+
+	if requeued != 3 {
+		t.Fatalf("deltas[\"hot\"] after partial-error flush = %d, want 3", requeued)
+	}
+
+	// A second flush, once primary stops erroring, must drain the requeued
+	// decrements instead of leaving them buffered forever.
+	ls.flush()
+
+	ls.mu.Lock()
+	remaining := ls.deltas["hot"]
+	ls.mu.Unlock()
+
+	if remaining != 0 {
+		t.Errorf("deltas[\"hot\"] after second flush = %d, want 0", remaining)
+	}
+}
@@ -0,0 +1,210 @@
+package ratelimiter
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+const defaultShardCount = 256
+const defaultSweepInterval = time.Minute
+
+// MemoryConfig - struct for configure the in-memory store
+//
+// For example if you want to set the limit of requests per second to 10 req per sec
+// MemoryConfig{ Interval: time.Second * 1, Points: 10}
+// or 20 req per 2 minutes MemoryConfig{ Interval: time.Minute * 2, Points: 20}
+type MemoryConfig struct {
+	// limiter interval
+	Interval time.Duration
+	// limiter max points
+	Points uint64
+	// number of shards, defaults to 256
+	Shards int
+	// how often expired buckets are swept, defaults to one minute
+	SweepInterval time.Duration
+}
+
+// bucket is a single rate-limit window kept in memory
+type bucket struct {
+	limit     uint64
+	remaining uint64
+	resetTime uint64
+}
+
+// shard is one of the N independently-locked partitions of the memory store
+type shard struct {
+	mu      sync.RWMutex
+	buckets map[string]*bucket
+}
+
+var _ Store = (*memoryStore)(nil)
+
+// memoryStore impl of Store, keeping buckets in sharded in-process maps
+type memoryStore struct {
+	shards    []*shard
+	maxPoints uint64
+	interval  time.Duration
+
+	done chan struct{}
+}
+
+// NewMemoryStore make an in-memory store, for standalone deployments that
+// don't want to run Redis just for rate limiting. Buckets are partitioned
+// across N sharded RWMutexes (hashed with FNV-1a) to minimize lock
+// contention, with a background sweeper evicting expired buckets.
+func NewMemoryStore(cfg MemoryConfig) Store {
+	shardCount := cfg.Shards
+	if shardCount <= 0 {
+		shardCount = defaultShardCount
+	}
+
+	sweepInterval := cfg.SweepInterval
+	if sweepInterval <= 0 {
+		sweepInterval = defaultSweepInterval
+	}
+
+	shards := make([]*shard, shardCount)
+	for i := range shards {
+		shards[i] = &shard{buckets: make(map[string]*bucket)}
+	}
+
+	s := &memoryStore{
+		shards:    shards,
+		maxPoints: cfg.Points,
+		interval:  cfg.Interval,
+		done:      make(chan struct{}),
+	}
+
+	go s.sweepLoop(sweepInterval)
+
+	return s
+}
+
+// Take returns the actual data on the key or creates a new key. Returns the number of tokens remaining, reset time
+func (s *memoryStore) Take(ctx context.Context, key string) (limit, remaining, resetTime uint64, ok bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return 0, 0, 0, false, err
+	}
+
+	limit, remaining, resetTime, ok = s.take(key)
+
+	return limit, remaining, resetTime, ok, nil
+}
+
+// Reset clean the memory store
+func (s *memoryStore) Reset(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		sh.buckets = make(map[string]*bucket)
+		sh.mu.Unlock()
+	}
+
+	return nil
+}
+
+// TakeExcl is like Take but, when f(key) reports ok, initializes the bucket
+// (if absent or expired) using the caller-supplied limit/interval instead of
+// the store's defaults. As with Take, an existing unexpired bucket keeps its
+// original limit; the override only takes effect when the bucket is
+// (re)created.
+func (s *memoryStore) TakeExcl(ctx context.Context, key string, f ExclFunc) (limit, remaining, resetTime uint64, ok bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return 0, 0, 0, false, err
+	}
+
+	maxPoints, interval := s.maxPoints, s.interval
+	if f != nil {
+		if exclOk, exclLimit, exclInterval := f(key); exclOk {
+			maxPoints, interval = exclLimit, exclInterval
+		}
+	}
+
+	limit, remaining, resetTime, ok = s.takeWithParams(key, maxPoints, interval)
+
+	return limit, remaining, resetTime, ok, nil
+}
+
+func (s *memoryStore) take(key string) (limit, remaining, resetTime uint64, ok bool) {
+	return s.takeWithParams(key, s.maxPoints, s.interval)
+}
+
+// takeWithParams is the shared entry point for Take and TakeExcl; maxPoints
+// and interval are only used when the bucket has to be (re)initialized.
+func (s *memoryStore) takeWithParams(key string, maxPoints uint64, interval time.Duration) (limit, remaining, resetTime uint64, ok bool) {
+	sh := s.shardFor(key)
+
+	now := uint64(time.Now().UnixNano())
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	b, found := sh.buckets[key]
+	if !found || now >= b.resetTime {
+		b = &bucket{
+			limit:     maxPoints,
+			remaining: maxPoints,
+			resetTime: uint64(time.Now().Add(interval).UnixNano()),
+		}
+		sh.buckets[key] = b
+	}
+
+	if b.remaining > 0 {
+		b.remaining--
+
+		return b.limit, b.remaining, b.resetTime, true
+	}
+
+	return b.limit, b.remaining, b.resetTime, false
+}
+
+func (s *memoryStore) shardFor(key string) *shard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+
+	return s.shards[h.Sum32()%uint32(len(s.shards))]
+}
+
+func (s *memoryStore) sweepLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.sweep()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Close stops the background sweeper goroutine. Safe to call once; callers
+// that create short-lived stores (tests, per-tenant instances, config
+// reloads) should call it to avoid leaking the goroutine for the life of the
+// process.
+func (s *memoryStore) Close() error {
+	close(s.done)
+
+	return nil
+}
+
+// sweep evicts buckets whose resetTime has passed
+func (s *memoryStore) sweep() {
+	now := uint64(time.Now().UnixNano())
+
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		for key, b := range sh.buckets {
+			if now >= b.resetTime {
+				delete(sh.buckets, key)
+			}
+		}
+		sh.mu.Unlock()
+	}
+}
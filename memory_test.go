@@ -0,0 +1,147 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	t.Parallel()
+
+	s := NewMemoryStore(MemoryConfig{Interval: time.Minute, Points: 2})
+	t.Cleanup(func() { _ = s.(io.Closer).Close() })
+
+	ctx := context.Background()
+
+	for i, want := range []uint64{1, 0} {
+		_, remaining, _, ok, err := s.Take(ctx, "key")
+		if err != nil {
+			t.Fatalf("take %d: %v", i, err)
+		}
+
+		if !ok {
+			t.Fatalf("take %d: expected ok=true", i)
+		}
+
+		if remaining != want {
+			t.Errorf("take %d: remaining = %d, want %d", i, remaining, want)
+		}
+	}
+
+	if _, _, _, ok, err := s.Take(ctx, "key"); err != nil || ok {
+		t.Errorf("take 3: expected ok=false, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestMemoryStoreReset(t *testing.T) {
+	t.Parallel()
+
+	s := NewMemoryStore(MemoryConfig{Interval: time.Minute, Points: 1})
+	t.Cleanup(func() { _ = s.(io.Closer).Close() })
+
+	ctx := context.Background()
+
+	if _, _, _, ok, err := s.Take(ctx, "key"); err != nil || !ok {
+		t.Fatalf("take: ok=%v err=%v", ok, err)
+	}
+
+	if err := s.Reset(ctx); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+
+	if _, _, _, ok, err := s.Take(ctx, "key"); err != nil || !ok {
+		t.Errorf("take after reset: ok=%v err=%v", ok, err)
+	}
+}
+
+func TestMemoryStoreTakeExcl(t *testing.T) {
+	t.Parallel()
+
+	s := NewMemoryStore(MemoryConfig{Interval: time.Minute, Points: 1})
+	t.Cleanup(func() { _ = s.(io.Closer).Close() })
+
+	ctx := context.Background()
+
+	// f's override only applies when the bucket is (re)created, so the
+	// first TakeExcl on a fresh key should honor the exclusive limit instead
+	// of the store's default of 1.
+	excl := func(string) (bool, uint64, time.Duration) { return true, 5, time.Minute }
+
+	limit, remaining, _, ok, err := s.TakeExcl(ctx, "key", excl)
+	if err != nil || !ok {
+		t.Fatalf("take excl: ok=%v err=%v", ok, err)
+	}
+
+	if limit != 5 || remaining != 4 {
+		t.Errorf("take excl: limit=%d remaining=%d, want limit=5 remaining=4", limit, remaining)
+	}
+
+	// Once the bucket exists, a plain Take keeps honoring the exclusive
+	// limit that created it.
+	if limit, _, _, _, err := s.Take(ctx, "key"); err != nil || limit != 5 {
+		t.Errorf("take after excl: limit=%d err=%v, want limit=5", limit, err)
+	}
+}
+
+func TestLimiterMiddlewareMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	s := NewMemoryStore(MemoryConfig{Interval: time.Minute, Points: 1})
+	t.Cleanup(func() { _ = s.(io.Closer).Close() })
+
+	mx := http.NewServeMux()
+	mw := LimiterMiddleware(s, func(r *http.Request) (string, error) {
+		return "1234", nil
+	})
+
+	mx.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	srv := httptest.NewServer(mw(mx))
+	defer srv.Close()
+
+	client := srv.Client()
+
+	res, err := client.Get(srv.URL + "/test")
+	if err != nil {
+		t.Fatalf("do request: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("first request status = %d, want 200", res.StatusCode)
+	}
+
+	res2, err := client.Get(srv.URL + "/test")
+	if err != nil {
+		t.Fatalf("do request: %v", err)
+	}
+	defer res2.Body.Close()
+
+	if res2.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("second request status = %d, want 429", res2.StatusCode)
+	}
+}
+
+func BenchmarkMemoryStoreTake(b *testing.B) {
+	s := NewMemoryStore(MemoryConfig{Interval: time.Second, Points: uint64(b.N + 1)})
+	b.Cleanup(func() { _ = s.(io.Closer).Close() })
+
+	ctx := context.Background()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := fmt.Sprintf("key-%d", i%64)
+			_, _, _, _, _ = s.Take(ctx, key)
+			i++
+		}
+	})
+}
@@ -36,8 +36,10 @@ type Option func(*Options)
 
 // Options for middleware
 type Options struct {
-	dateFormat string
-	skipper    func() bool
+	dateFormat    string
+	skipper       func() bool
+	exclFuncMaker func(r *http.Request) ExclFunc
+	varyByKeyFunc KeyFunc
 }
 
 // WithDateFormat set custom date format into HeaderRetryAfter/HeaderRateLimitReset
@@ -54,6 +56,26 @@ func WithSkipper(skipper func() bool) Option {
 	}
 }
 
+// WithExclFunc derives an ExclFunc from the incoming request, letting
+// services bump the limit/interval for a request at request time (e.g.
+// a paid tier vs a free tier) without allocating a separate middleware per
+// key class. When the derived ExclFunc is non-nil, Store.TakeExcl is used
+// instead of Store.Take.
+func WithExclFunc(f func(r *http.Request) ExclFunc) Option {
+	return func(options *Options) {
+		options.exclFuncMaker = f
+	}
+}
+
+// WithVaryBy derives the request key from v instead of the KeyFunc passed to
+// LimiterMiddleware; pass a nil keyFunc to LimiterMiddleware when using this
+// option, the two are mutually exclusive.
+func WithVaryBy(v VaryBy, opts ...VaryByOption) Option {
+	return func(options *Options) {
+		options.varyByKeyFunc = v.KeyFunc(opts...)
+	}
+}
+
 // IPKeyFunc rate limit by ip
 func IPKeyFunc(headers ...string) KeyFunc {
 	return func(r *http.Request) (string, error) {
@@ -85,6 +107,11 @@ func LimiterMiddleware(s Store, keyFunc KeyFunc, opts ...Option) func(next http.
 	// define options
 	dateFormat := opt.dateFormat
 	skipperFn := opt.skipper
+	exclFuncMaker := opt.exclFuncMaker
+
+	if opt.varyByKeyFunc != nil {
+		keyFunc = opt.varyByKeyFunc
+	}
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -116,8 +143,21 @@ func LimiterMiddleware(s Store, keyFunc KeyFunc, opts ...Option) func(next http.
 				return
 			}
 
+			var exclFunc ExclFunc
+			if exclFuncMaker != nil {
+				exclFunc = exclFuncMaker(r)
+			}
+
 			// fetching limit, remaining and reset time from store
-			limit, remaining, t, ok, err := s.Take(ctx, key)
+			var (
+				limit, remaining, t uint64
+				ok                  bool
+			)
+			if exclFunc != nil {
+				limit, remaining, t, ok, err = s.TakeExcl(ctx, key, exclFunc)
+			} else {
+				limit, remaining, t, ok, err = s.Take(ctx, key)
+			}
 			if err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -136,6 +137,201 @@ func TestLimiterMiddleware(t *testing.T) {
 	}
 }
 
+func TestLimiterMiddlewareExclFunc(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+
+		exclOk       bool
+		exclLimit    uint64
+		exclInterval time.Duration
+
+		expectedLimit string
+	}{
+		{
+			name:      "test_excl_applied",
+			exclOk:    true,
+			exclLimit: 1000,
+
+			expectedLimit: "1000",
+		},
+		{
+			name:   "test_excl_not_applied",
+			exclOk: false,
+
+			expectedLimit: "10",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mx := http.NewServeMux()
+			deps := testProvideMockDeps(t)
+			deps.store.
+				EXPECT().
+				TakeExcl(gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(tc.exclLimit, tc.exclLimit, uint64(0), true, nil).
+				AnyTimes()
+			deps.store.
+				EXPECT().
+				Take(gomock.Any(), gomock.Any()).
+				Return(uint64(10), uint64(10), uint64(0), true, nil).
+				AnyTimes()
+
+			mw := LimiterMiddleware(deps.store, func(r *http.Request) (string, error) {
+				return "1234", nil
+			}, WithExclFunc(func(r *http.Request) ExclFunc {
+				if !tc.exclOk {
+					return nil
+				}
+
+				return func(key string) (ok bool, limit uint64, interval time.Duration) {
+					return tc.exclOk, tc.exclLimit, tc.exclInterval
+				}
+			}))
+
+			mx.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			s := httptest.NewServer(mw(mx))
+
+			client := s.Client()
+
+			u, err := url.Parse(s.URL + "/test")
+			if err != nil {
+				t.Fatalf("url parse: %v", err)
+			}
+
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, u.String(), http.NoBody)
+			if err != nil {
+				t.Fatalf("unable create request: %v", err)
+
+				return
+			}
+
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("do request: %v", err)
+
+				return
+			}
+
+			defer res.Body.Close()
+
+			if diff := cmp.Diff(tc.expectedLimit, res.Header.Get(HeaderRateLimitLimit)); diff != "" {
+				t.Errorf("bad body (+got, -want): %s", diff)
+			}
+		})
+	}
+}
+
+func TestVaryByKeyFunc(t *testing.T) {
+	t.Parallel()
+
+	vary := VaryBy{RemoteAddr: true, Method: true, Path: true, Headers: []string{"Authorization"}, Cookies: []string{"sid"}}
+	keyFunc := vary.KeyFunc()
+
+	req1, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://example.com/test", http.NoBody)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req1.RemoteAddr = "127.0.0.1:1234"
+	req1.Header.Set("authorization", "Bearer token")
+
+	req2, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://example.com/test", http.NoBody)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req2.RemoteAddr = "127.0.0.1:5678"
+	req2.Header.Set("Authorization", "Bearer token")
+
+	key1, err := keyFunc(req1)
+	if err != nil {
+		t.Fatalf("keyFunc req1: %v", err)
+	}
+
+	key2, err := keyFunc(req2)
+	if err != nil {
+		t.Fatalf("keyFunc req2: %v", err)
+	}
+
+	if diff := cmp.Diff(key1, key2); diff != "" {
+		t.Errorf("keys differ across header-case/port variation (+got, -want): %s", diff)
+	}
+
+	req3, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://example.com/test", http.NoBody)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req3.RemoteAddr = "127.0.0.1:1234"
+
+	key3, err := keyFunc(req3)
+	if err != nil {
+		t.Fatalf("keyFunc req3: %v", err)
+	}
+
+	if key3 == key1 {
+		t.Errorf("expected a missing Authorization header to produce a different key")
+	}
+}
+
+func TestVaryByKeyFuncHashThreshold(t *testing.T) {
+	t.Parallel()
+
+	vary := VaryBy{Path: true}
+	keyFunc := vary.KeyFunc(WithVaryByHashThreshold(8))
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://example.com/a/very/long/path/that/exceeds/the/threshold", http.NoBody)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+
+	key, err := keyFunc(req)
+	if err != nil {
+		t.Fatalf("keyFunc: %v", err)
+	}
+
+	if !strings.HasPrefix(key, "sha256:") {
+		t.Errorf("expected hashed key, got %q", key)
+	}
+}
+
+func TestLimiterMiddlewareVaryBy(t *testing.T) {
+	t.Parallel()
+
+	mx := http.NewServeMux()
+	deps := testProvideMockDeps(t)
+	deps.store.
+		EXPECT().
+		Take(gomock.Any(), gomock.Any()).
+		Return(uint64(10), uint64(3), uint64(0), true, nil).
+		AnyTimes()
+
+	mw := LimiterMiddleware(deps.store, nil, WithVaryBy(VaryBy{RemoteAddr: true, Path: true}))
+
+	mx.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	s := httptest.NewServer(mw(mx))
+	defer s.Close()
+
+	res, err := s.Client().Get(s.URL + "/test")
+	if err != nil {
+		t.Fatalf("do request: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", res.StatusCode)
+	}
+}
+
 type mockDeps struct {
 	ctrl  *gomock.Controller
 	store *MockStore
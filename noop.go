@@ -11,9 +11,16 @@ func (s *noopStore) Reset(ctx context.Context) error {
 	return nil
 }
 
-func (s *noopStore) TakeExcl(ctx context.Context, key string, f ExclFunc) (limit, remaining, resetTime uint64, ok bool, err error) {
-	// TODO implement me
-	panic("implement me")
+// TakeExcl always positive, using the exclusive limit as both limit and
+// remaining when f reports ok
+func (s *noopStore) TakeExcl(_ context.Context, key string, f ExclFunc) (limit, remaining, resetTime uint64, ok bool, err error) {
+	if f != nil {
+		if exclOk, exclLimit, _ := f(key); exclOk {
+			return exclLimit, exclLimit, 0, true, nil
+		}
+	}
+
+	return 0, 0, 0, true, nil
 }
 
 func NewNoop() Store { return &noopStore{} }
@@ -2,9 +2,9 @@ package ratelimiter
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	redis "github.com/go-redis/redis/v8"
@@ -23,8 +23,59 @@ const (
 	defaultRedisPrefix    = "rate_limiter_redistore_"
 	defaultRedisTag       = "goratelimit"
 	defaultRedisTagPrefix = "tags:"
+	defaultPipelineLimit  = 128
 )
 
+// takeScript atomically reads the current bucket and decrements it. A bucket
+// that is initialized (on first use or after the window has rolled over) is
+// registered with the invalidation tags and reports the full allowance
+// without consuming a point. An existing bucket reports the max_points stored
+// in its hash. KEYS[1] is the bucket key, KEYS[2:] are the tag set keys.
+// ARGV: maxPoints, intervalNanos, nowNanos, ttlMillis.
+//
+// Running the whole read/init/decrement/return flow as one script closes the
+// race window the previous HMGet-then-HSet implementation had, where two
+// concurrent callers could both observe remaining=1 and both succeed.
+const takeScript = `
+local key = KEYS[1]
+local max_points = tonumber(ARGV[1])
+local interval_ns = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local ttl_ms = tonumber(ARGV[4])
+
+local reset_time = tonumber(redis.call("HGET", key, "reset_time"))
+local actual = tonumber(redis.call("HGET", key, "actual_points"))
+
+if reset_time == nil or now >= reset_time then
+	actual = max_points
+	reset_time = now + interval_ns
+
+	redis.call("HMSET", key, "max_points", max_points, "actual_points", actual, "reset_time", reset_time)
+	redis.call("PEXPIRE", key, ttl_ms)
+
+	for i = 2, #KEYS do
+		redis.call("SADD", KEYS[i], key)
+		redis.call("PEXPIRE", KEYS[i], ttl_ms)
+	end
+
+	return {max_points, actual, reset_time, 1}
+end
+
+local stored_max = tonumber(redis.call("HGET", key, "max_points"))
+if stored_max ~= nil then
+	max_points = stored_max
+end
+
+if actual > 0 then
+	actual = actual - 1
+	redis.call("HSET", key, "actual_points", actual)
+
+	return {max_points, actual, reset_time, 1}
+end
+
+return {max_points, actual, reset_time, 0}
+`
+
 // RedisConfig - struct for configure redis store
 //
 // For example if you want to set the limit of requests per second to 10 req per sec
@@ -41,6 +92,13 @@ type RedisConfig struct {
 	Interval time.Duration
 	// limiter max points
 	Points uint64
+	// PipelineWindow, when non-zero, coalesces Take calls arriving within the
+	// window into a single Redis pipeline round trip. Zero disables
+	// coalescing: every Take is sent to Redis immediately (current behavior).
+	PipelineWindow time.Duration
+	// PipelineLimit caps how many coalesced calls are sent in one pipeline
+	// flush, even if PipelineWindow hasn't elapsed yet. Defaults to 128.
+	PipelineLimit int
 }
 
 type RedisClient interface {
@@ -49,7 +107,11 @@ type RedisClient interface {
 	Del(ctx context.Context, keys ...string) *redis.IntCmd
 	Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd
 	TxPipeline() redis.Pipeliner
+	Pipeline() redis.Pipeliner
 	SMembers(ctx context.Context, key string) *redis.StringSliceCmd
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd
+	EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd
+	ScriptLoad(ctx context.Context, script string) *redis.StringCmd
 }
 
 // NewRedisStore make redis store
@@ -76,14 +138,28 @@ func NewRedisStore(instance RedisClient, cfg RedisConfig) Store {
 		}
 	}
 
-	return &redisStore{
-		client:    instance,
-		prefix:    prefix,
-		interval:  cfg.Interval,
-		maxPoints: cfg.Points,
-		tags:      tags,
-		tagPrefix: tagPrefix,
+	r := &redisStore{
+		client:         instance,
+		prefix:         prefix,
+		interval:       cfg.Interval,
+		maxPoints:      cfg.Points,
+		tags:           tags,
+		tagPrefix:      tagPrefix,
+		pipelineWindow: cfg.PipelineWindow,
+		pipelineLimit:  cfg.PipelineLimit,
 	}
+
+	if r.pipelineWindow > 0 {
+		if r.pipelineLimit <= 0 {
+			r.pipelineLimit = defaultPipelineLimit
+		}
+
+		r.pipelineCh = make(chan *pipelineRequest, r.pipelineLimit)
+
+		go r.pipelineLoop()
+	}
+
+	return r
 }
 
 var _ Store = (*redisStore)(nil)
@@ -97,10 +173,17 @@ type redisStore struct {
 	prefix    string
 	maxPoints uint64
 	interval  time.Duration
+
+	scriptMu  sync.RWMutex
+	scriptSHA string
+
+	pipelineWindow time.Duration
+	pipelineLimit  int
+	pipelineCh     chan *pipelineRequest
 }
 
 // Take returns the actual data on the key or creates a new key. Returns the number of tokens remaining, reset time
-func (r redisStore) Take(ctx context.Context, key string) (limit, remaining, resetTime uint64, ok bool, err error) {
+func (r *redisStore) Take(ctx context.Context, key string) (limit, remaining, resetTime uint64, ok bool, err error) {
 	limit, remaining, resetTime, ok, err = r.take(ctx, key)
 	if err != nil {
 		return 0, 0, 0, false, fmt.Errorf("take: %w", err)
@@ -110,7 +193,7 @@ func (r redisStore) Take(ctx context.Context, key string) (limit, remaining, res
 }
 
 // Reset clean redis store
-func (r redisStore) Reset(ctx context.Context) error {
+func (r *redisStore) Reset(ctx context.Context) error {
 	if err := r.reset(ctx); err != nil {
 		return fmt.Errorf("reset: %w", err)
 	}
@@ -118,97 +201,224 @@ func (r redisStore) Reset(ctx context.Context) error {
 	return nil
 }
 
-// TakeExcl not implemented yet
-func (r redisStore) TakeExcl(ctx context.Context, key string, f ExclFunc) (limit, remaining, resetTime uint64, ok bool, err error) {
-	// TODO implement me
-	panic("implement me")
+// TakeExcl is like Take but, when f(key) reports ok, initializes the bucket
+// (if absent) using the caller-supplied limit/interval instead of the
+// store's defaults. Because the effective limit is persisted in the hash,
+// subsequent Take calls on the same key keep honoring the exception until
+// the bucket expires.
+func (r *redisStore) TakeExcl(ctx context.Context, key string, f ExclFunc) (limit, remaining, resetTime uint64, ok bool, err error) {
+	limit, remaining, resetTime, ok, err = r.takeExcl(ctx, key, f)
+	if err != nil {
+		return 0, 0, 0, false, fmt.Errorf("take excl: %w", err)
+	}
+
+	return limit, remaining, resetTime, ok, nil
 }
 
-func (r redisStore) take(ctx context.Context, key string) (limit, remaining, resetTimeUint uint64, ok bool, err error) {
+func (r *redisStore) take(ctx context.Context, key string) (limit, remaining, resetTime uint64, ok bool, err error) {
 	prefixedKey := fmt.Sprintf("%s%s", r.prefix, key)
 
-	//Trying to get points from the current key
-	vals, err := r.client.HMGet(ctx, prefixedKey, redisMaxPointsFieldName, redisActualPointsFieldName, redisResetTimeFieldName).Result()
-	if err != nil {
-		if errors.Is(err, redis.Nil) {
-			return r.newBucket(ctx, prefixedKey)
-		}
+	return r.takeWithParams(ctx, prefixedKey, r.maxPoints, r.interval)
+}
+
+func (r *redisStore) takeExcl(ctx context.Context, key string, f ExclFunc) (limit, remaining, resetTime uint64, ok bool, err error) {
+	prefixedKey := fmt.Sprintf("%s%s", r.prefix, key)
 
-		return 0, 0, 0, false, fmt.Errorf("HMGet: %w", err)
+	maxPoints, interval := r.maxPoints, r.interval
+	if f != nil {
+		if exclOk, exclLimit, exclInterval := f(key); exclOk {
+			maxPoints, interval = exclLimit, exclInterval
+		}
 	}
 
-	if vals[0] == nil {
-		// If the first element is nil, need to create a new value in redis
-		return r.newBucket(ctx, prefixedKey)
+	return r.takeWithParams(ctx, prefixedKey, maxPoints, interval)
+}
+
+// takeWithParams is the shared entry point for Take and TakeExcl; maxPoints
+// and interval are only used when the bucket has to be (re)initialized.
+func (r *redisStore) takeWithParams(ctx context.Context, prefixedKey string, maxPoints uint64, interval time.Duration) (limit, remaining, resetTime uint64, ok bool, err error) {
+	if r.pipelineWindow > 0 {
+		return r.takeViaPipeline(ctx, prefixedKey, maxPoints, interval)
 	}
 
-	var v string
+	return r.takeDirect(ctx, prefixedKey, maxPoints, interval)
+}
 
-	{
-		// Limit type assertion
-		v, ok = vals[0].(string)
-		if !ok {
-			return 0, 0, 0, false, fmt.Errorf("redis mismatch types")
+// takeDirect runs the Lua script via EVALSHA, loading and retrying with EVAL
+// on a cache miss (NOSCRIPT), which also happens the very first time the
+// script is used.
+func (r *redisStore) takeDirect(ctx context.Context, prefixedKey string, maxPoints uint64, interval time.Duration) (limit, remaining, resetTime uint64, ok bool, err error) {
+	keys := r.scriptKeys(prefixedKey)
+	args := r.scriptArgs(maxPoints, interval)
+
+	sha := r.loadedSHA()
+	if sha != "" {
+		res, evalErr := r.client.EvalSha(ctx, sha, keys, args...).Result()
+		if evalErr == nil {
+			return parseTakeReply(res)
 		}
 
-		// parse limit field to uint64
-		l, pErr := strconv.ParseUint(v, 0, 64)
-		if err != nil {
-			return 0, 0, 0, false, fmt.Errorf("parse uint: %w", pErr)
+		if !isNoScriptErr(evalErr) {
+			return 0, 0, 0, false, fmt.Errorf("evalsha: %w", evalErr)
 		}
+	}
 
-		limit = l
+	sha, err = r.client.ScriptLoad(ctx, takeScript).Result()
+	if err != nil {
+		return 0, 0, 0, false, fmt.Errorf("script load: %w", err)
 	}
 
-	{
-		// Remaining tokens type assertion
-		v, ok = vals[1].(string)
-		if !ok {
-			return 0, 0, 0, false, fmt.Errorf("redis mismatch types")
-		}
+	r.storeSHA(sha)
+
+	res, err := r.client.Eval(ctx, takeScript, keys, args...).Result()
+	if err != nil {
+		return 0, 0, 0, false, fmt.Errorf("eval: %w", err)
+	}
+
+	return parseTakeReply(res)
+}
+
+func (r *redisStore) loadedSHA() string {
+	r.scriptMu.RLock()
+	defer r.scriptMu.RUnlock()
+
+	return r.scriptSHA
+}
+
+func (r *redisStore) storeSHA(sha string) {
+	r.scriptMu.Lock()
+	defer r.scriptMu.Unlock()
+
+	r.scriptSHA = sha
+}
+
+func isNoScriptErr(err error) bool {
+	return strings.HasPrefix(err.Error(), "NOSCRIPT")
+}
 
-		// parse remaining field to uint64
-		a, pErr := strconv.ParseUint(v, 0, 64)
-		if err != nil {
-			return 0, 0, 0, false, fmt.Errorf("parse uint: %w", pErr)
+func (r *redisStore) scriptKeys(prefixedKey string) []string {
+	keys := make([]string, 0, len(r.tags)+1)
+	keys = append(keys, prefixedKey)
+	keys = append(keys, r.tags...)
+
+	return keys
+}
+
+func (r *redisStore) scriptArgs(maxPoints uint64, interval time.Duration) []interface{} {
+	return []interface{}{maxPoints, interval.Nanoseconds(), time.Now().UnixNano(), interval.Milliseconds()}
+}
+
+func parseTakeReply(res interface{}) (limit, remaining, resetTime uint64, ok bool, err error) {
+	vals, isSlice := res.([]interface{})
+	if !isSlice || len(vals) != 4 {
+		return 0, 0, 0, false, fmt.Errorf("take script: unexpected reply")
+	}
+
+	nums := make([]int64, 4)
+	for i, v := range vals {
+		n, isInt := v.(int64)
+		if !isInt {
+			return 0, 0, 0, false, fmt.Errorf("take script: mismatch types")
 		}
 
-		remaining = a
+		nums[i] = n
 	}
 
-	{
-		// Reset time type assertion
-		v, ok = vals[2].(string)
+	return uint64(nums[0]), uint64(nums[1]), uint64(nums[2]), nums[3] == 1, nil
+}
+
+// pipelineRequest is a single Take call coalesced into a shared Redis pipeline.
+type pipelineRequest struct {
+	prefixedKey string
+	maxPoints   uint64
+	interval    time.Duration
+	respCh      chan takeReply
+}
+
+type takeReply struct {
+	limit, remaining, resetTime uint64
+	ok                          bool
+	err                         error
+}
+
+// takeViaPipeline hands the request to the background pipeline loop and
+// waits for its batched reply.
+func (r *redisStore) takeViaPipeline(ctx context.Context, prefixedKey string, maxPoints uint64, interval time.Duration) (limit, remaining, resetTime uint64, ok bool, err error) {
+	req := &pipelineRequest{prefixedKey: prefixedKey, maxPoints: maxPoints, interval: interval, respCh: make(chan takeReply, 1)}
+
+	select {
+	case r.pipelineCh <- req:
+	case <-ctx.Done():
+		return 0, 0, 0, false, ctx.Err()
+	}
+
+	select {
+	case reply := <-req.respCh:
+		return reply.limit, reply.remaining, reply.resetTime, reply.ok, reply.err
+	case <-ctx.Done():
+		return 0, 0, 0, false, ctx.Err()
+	}
+}
+
+// pipelineLoop batches queued requests and flushes them either when
+// PipelineWindow elapses or PipelineLimit requests have accumulated.
+func (r *redisStore) pipelineLoop() {
+	for {
+		req, ok := <-r.pipelineCh
 		if !ok {
-			return 0, 0, 0, false, fmt.Errorf("redis mismatch types")
+			return
 		}
 
-		// parse reset time field to uint64
-		t, pErr := strconv.ParseUint(v, 0, 64)
-		if err != nil {
-			return 0, 0, 0, false, fmt.Errorf("parse uint: %w", pErr)
+		batch := []*pipelineRequest{req}
+		timer := time.NewTimer(r.pipelineWindow)
+
+	collect:
+		for len(batch) < r.pipelineLimit {
+			select {
+			case req := <-r.pipelineCh:
+				batch = append(batch, req)
+			case <-timer.C:
+				break collect
+			}
 		}
 
-		resetTimeUint = t
+		timer.Stop()
+
+		r.flushPipeline(batch)
+	}
+}
+
+// flushPipeline sends every queued Take as one EVAL in a single pipeline
+// round trip and fans the replies back out to each caller.
+func (r *redisStore) flushPipeline(batch []*pipelineRequest) {
+	ctx := context.Background()
+	pipe := r.client.Pipeline()
+
+	cmds := make([]*redis.Cmd, len(batch))
+	for i, req := range batch {
+		cmds[i] = pipe.Eval(ctx, takeScript, r.scriptKeys(req.prefixedKey), r.scriptArgs(req.maxPoints, req.interval)...)
 	}
 
-	// Reduce the number of tokens by one in case of success
-	if remaining > 0 {
-		// decrement remaining points
-		remaining--
+	// Exec's own error only reports that at least one queued command failed;
+	// go-redis still populates a Result()/Err() for every command that
+	// succeeded, so we check each one individually rather than failing the
+	// whole batch on an unrelated sibling's error.
+	_, _ = pipe.Exec(ctx)
+
+	for i, req := range batch {
+		res, cmdErr := cmds[i].Result()
+		if cmdErr != nil {
+			req.respCh <- takeReply{err: fmt.Errorf("eval: %w", cmdErr)}
 
-		// Update actual tokens
-		if err = r.update(ctx, prefixedKey, []string{redisActualPointsFieldName, strconv.FormatUint(remaining, 10)}); err != nil {
-			return 0, 0, 0, true, fmt.Errorf("redis hmset: %w", err)
+			continue
 		}
 
-		return limit, remaining, resetTimeUint, true, nil
+		limit, remaining, resetTime, ok, parseErr := parseTakeReply(res)
+		req.respCh <- takeReply{limit: limit, remaining: remaining, resetTime: resetTime, ok: ok, err: parseErr}
 	}
-
-	return limit, remaining, resetTimeUint, false, nil
 }
 
-func (r redisStore) reset(ctx context.Context) error {
+func (r *redisStore) reset(ctx context.Context) error {
 	pipe := r.client.TxPipeline()
 
 	defer pipe.Close()
@@ -235,51 +445,3 @@ func (r redisStore) reset(ctx context.Context) error {
 
 	return nil
 }
-
-// update actual tokens
-func (r redisStore) update(ctx context.Context, key string, fields []string) error {
-	if err := r.client.HSet(ctx, key, fields).Err(); err != nil {
-		return fmt.Errorf("redis hset: %w", err)
-	}
-
-	return nil
-}
-
-// hset execute redis HSET and expire command with transaction
-func (r redisStore) hset(ctx context.Context, key string, fields []string, expiration time.Duration) error {
-	pipe := r.client.TxPipeline()
-
-	defer pipe.Close()
-
-	// Update tag set
-	for _, tag := range r.tags {
-		// Add key to tag set
-		_ = pipe.Process(ctx, pipe.SAdd(ctx, tag, key))
-		// Set expire
-		_ = pipe.Process(ctx, pipe.Expire(ctx, tag, expiration))
-	}
-
-	_ = pipe.Process(ctx, pipe.HSet(ctx, key, fields))
-	_ = pipe.Process(ctx, pipe.Expire(ctx, key, expiration))
-
-	if _, err := pipe.Exec(ctx); err != nil {
-		return fmt.Errorf("redis pipeline exec: %w", err)
-	}
-
-	return nil
-}
-
-// newBucket helper for create new bucket
-func (r redisStore) newBucket(ctx context.Context, key string) (limit, remaining, resetTimeUint uint64, ok bool, err error) {
-	actual, resetTime := r.maxPoints, uint64(time.Now().Add(r.interval).UnixNano())
-
-	if err = r.hset(
-		ctx, key, []string{redisMaxPointsFieldName, strconv.FormatUint(r.maxPoints, 10),
-			redisActualPointsFieldName, strconv.FormatUint(actual, 10),
-			redisResetTimeFieldName, strconv.FormatUint(resetTime, 10),
-		}, r.interval); err != nil {
-		return 0, 0, 0, false, fmt.Errorf("redis hmset: %w", err)
-	}
-
-	return r.maxPoints, r.maxPoints, resetTime, true, nil
-}
@@ -0,0 +1,74 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redis "github.com/go-redis/redis/v8"
+)
+
+// TestRedisStorePipelineIsolatesPerKeyErrors guards against flushPipeline
+// gating every coalesced caller's reply on the pipeline's aggregate Exec
+// error. One key whose bucket hits a WRONGTYPE error server-side must not
+// cause a sibling key's successful Take, batched into the same pipeline
+// flush, to surface as an error too.
+func TestRedisStorePipelineIsolatesPerKeyErrors(t *testing.T) {
+	t.Parallel()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis run: %v", err)
+	}
+	defer mr.Close()
+
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	// Seed a non-hash value under the key redisStore would otherwise use as
+	// a hash, forcing HGET to fail with WRONGTYPE for this key only.
+	if err := mr.Set(defaultRedisPrefix+"bad", "not-a-hash"); err != nil {
+		t.Fatalf("miniredis set: %v", err)
+	}
+
+	s := NewRedisStore(client, RedisConfig{
+		Interval:       time.Minute,
+		Points:         5,
+		PipelineWindow: 50 * time.Millisecond,
+		PipelineLimit:  10,
+	})
+
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+
+	var goodErr, badErr error
+
+	var goodOK bool
+
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+
+		_, _, _, goodOK, goodErr = s.Take(ctx, "good")
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		_, _, _, _, badErr = s.Take(ctx, "bad")
+	}()
+
+	wg.Wait()
+
+	if goodErr != nil || !goodOK {
+		t.Errorf("sibling key: ok=%v err=%v, want ok=true err=nil", goodOK, goodErr)
+	}
+
+	if badErr == nil {
+		t.Errorf("expected an error for the WRONGTYPE key, got nil")
+	}
+}